@@ -0,0 +1,76 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes how long to sleep after a run of consecutive failures.
+type Backoff interface {
+	// Next returns the delay to sleep after `attempt` consecutive failures.
+	// attempt is 1 on the first failure, 2 on the second, and so on.
+	Next(attempt int) time.Duration
+}
+
+// ExponentialBackoff grows the delay geometrically from Base towards Max,
+// optionally applying full jitter so many replicas failing at once don't
+// retry in lockstep.
+type ExponentialBackoff struct {
+	// Base is the delay after the first failure.
+	Base time.Duration
+	// Max caps the computed delay. Zero means uncapped.
+	Max time.Duration
+	// Factor multiplies the delay on each additional failure. Defaults to 2
+	// when zero or negative.
+	Factor float64
+	// Jitter, when true, returns a uniformly random delay in [0, computed)
+	// instead of the computed delay itself.
+	Jitter bool
+}
+
+// Next implements Backoff.
+func (b ExponentialBackoff) Next(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	factor := b.Factor
+	if factor <= 0 {
+		factor = 2
+	}
+
+	delay := float64(b.Base) * math.Pow(factor, float64(attempt-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	// A large enough attempt count (with Max unset or very large) overflows
+	// what a time.Duration can represent. float64(math.MaxInt64) itself
+	// rounds up to 2^63, one past the largest int64, so clamping delay to it
+	// and then converting would still overflow; return the largest
+	// representable Duration directly instead of clamping the float.
+	if delay >= float64(math.MaxInt64) {
+		return time.Duration(math.MaxInt64)
+	}
+	sleep := time.Duration(delay)
+	if sleep <= 0 {
+		return 0
+	}
+	if b.Jitter {
+		sleep = time.Duration(rand.Int63n(int64(sleep)))
+	}
+	return sleep
+}
@@ -0,0 +1,51 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffNext(t *testing.T) {
+	b := ExponentialBackoff{Base: 100 * time.Millisecond, Max: 10 * time.Second}
+	if got := b.Next(1); got != 100*time.Millisecond {
+		t.Errorf("Next(1) = %v, want 100ms", got)
+	}
+	if got := b.Next(2); got != 200*time.Millisecond {
+		t.Errorf("Next(2) = %v, want 200ms", got)
+	}
+	if got := b.Next(10); got != 10*time.Second {
+		t.Errorf("Next(10) = %v, want capped at Max 10s", got)
+	}
+}
+
+// TestExponentialBackoffNextLargeAttemptUncapped guards against the
+// exponential growth overflowing int64 nanoseconds before an unset Max ever
+// gets a chance to clamp it: Base(100ms) * 2^49 alone is far beyond
+// time.Duration's range, and a naive float64->Duration cast there wraps to a
+// negative number that the sleep<=0 guard then collapses to "no delay" -
+// the opposite of what backoff is for.
+func TestExponentialBackoffNextLargeAttemptUncapped(t *testing.T) {
+	b := ExponentialBackoff{Base: 100 * time.Millisecond}
+	got := b.Next(50)
+	if got <= 0 {
+		t.Errorf("Next(50) = %v, want a large positive delay, not a collapsed zero", got)
+	}
+	if got != time.Duration(math.MaxInt64) {
+		t.Errorf("Next(50) = %v, want clamped to math.MaxInt64", got)
+	}
+}
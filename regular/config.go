@@ -0,0 +1,105 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config defines the behavior of an Engine.
+type Config struct {
+	// Name identifies the engine in logs. Defaults to "regular".
+	Name string
+	// Periods restricts task execution to one or more daily time windows,
+	// compared in the local time zone. Ignored once Schedule is set.
+	Periods []*Period
+	// Schedule, when set, takes precedence over Periods and drives the
+	// task on a cron-style schedule instead of a fixed daily window.
+	Schedule Scheduler
+	// FailInterval is the delay in milliseconds before retrying after a
+	// failed execution. A negative value stops the task (not the engine) on
+	// the first error, see TaskOptions.FailInterval. Ignored once Backoff is
+	// set.
+	FailInterval int64
+	// SuccessInterval is the delay in milliseconds before the next execution
+	// after a successful one. A negative value runs the task exactly once.
+	SuccessInterval int64
+	// Backoff, when set, replaces the flat FailInterval delay with a
+	// computed one based on the number of consecutive failures.
+	Backoff Backoff
+	// Leader, when set, gates Start behind leader election: only the
+	// engine replica holding the lock for this Config runs the task. The
+	// lock key defaults to Name.
+	Leader Locker
+	// LeaderTTL is the lease duration passed to Leader.Acquire. Defaults to
+	// 30s when zero. The lease is renewed at LeaderTTL/3.
+	LeaderTTL time.Duration
+	// Metrics, when set, records run counts, durations and window
+	// transitions for the task. Defaults to NopMetrics.
+	Metrics Metrics
+	// Tracer, when set, wraps each task.Run(ctx) call in a span.
+	Tracer Tracer
+}
+
+// Period describes a daily time window in "HH:MM-HH:MM" format, e.g. a
+// Period with Start "09:00" and End "18:00" only allows the task to run
+// between 9am and 6pm local time.
+type Period struct {
+	Start string
+	End   string
+
+	startHour, startMinute int
+	endHour, endMinute     int
+}
+
+// Parse validates Start and End and extracts their hour/minute components.
+func (p *Period) Parse() error {
+	var err error
+	p.startHour, p.startMinute, err = parseClock(p.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start %q: %v", p.Start, err)
+	}
+	p.endHour, p.endMinute, err = parseClock(p.End)
+	if err != nil {
+		return fmt.Errorf("invalid end %q: %v", p.End, err)
+	}
+	return nil
+}
+
+// window converts p to a Window in the local time zone, for use with
+// Window.State instead of the deprecated CheckTime.
+func (p *Period) window() Window {
+	return Window{
+		Start: time.Duration(p.startHour)*time.Hour + time.Duration(p.startMinute)*time.Minute,
+		End:   time.Duration(p.endHour)*time.Hour + time.Duration(p.endMinute)*time.Minute,
+	}
+}
+
+func parseClock(s string) (hour, minute int, err error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected HH:MM format")
+	}
+	if hour, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, err
+	}
+	if minute, err = strconv.Atoi(parts[1]); err != nil {
+		return 0, 0, err
+	}
+	return hour, minute, nil
+}
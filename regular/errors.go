@@ -0,0 +1,55 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrAlreadyStarted is returned by Start/Run when the engine is already
+// running.
+var ErrAlreadyStarted = errors.New("regular: engine already started")
+
+// ErrAlreadyStopped is returned by Shutdown when the engine is not running,
+// either because it was never started or Shutdown was already called.
+var ErrAlreadyStopped = errors.New("regular: engine already stopped")
+
+// engineState tracks Engine's lifecycle so Start/Shutdown/Wait are safe to
+// call concurrently and repeatedly.
+type engineState int32
+
+const (
+	stateStopped engineState = iota
+	stateStarting
+	stateRunning
+	stateStopping
+)
+
+func (e *Engine) isRunning() bool {
+	return engineState(atomic.LoadInt32(&e.state)) == stateRunning
+}
+
+// Wait blocks until the engine has fully stopped. It returns immediately if
+// the engine has never been started.
+func (e *Engine) Wait() {
+	e.m.Lock()
+	ch := e.stoppedCh
+	e.m.Unlock()
+	if ch == nil {
+		return
+	}
+	<-ch
+}
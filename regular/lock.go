@@ -0,0 +1,126 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"context"
+	"time"
+)
+
+// Lease represents a held distributed lock acquired through a Locker. It
+// must be renewed before ttl elapses or another replica may acquire the
+// same key.
+type Lease interface {
+	// Renew extends the lease for another ttl, as measured from the backing
+	// store's clock.
+	Renew(ctx context.Context) error
+	// Release gives up the lease early, allowing another replica to
+	// acquire it immediately.
+	Release(ctx context.Context) error
+}
+
+// Locker mediates leader election across Engine replicas so that only one
+// of them executes a given task at a time. Acquire may either block until
+// the lock is held or ctx is canceled (as lock/etcd does, via a campaign),
+// or make a single non-blocking attempt and return an error immediately if
+// the lock is already held (as lock/redis and lock/flock do); startWithLeader
+// treats any Acquire error the same way, retrying after a delay, so either
+// contract is safe to implement.
+type Locker interface {
+	Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error)
+}
+
+// defaultLeaderTTL is used when Config.LeaderTTL is unset.
+const defaultLeaderTTL = 30 * time.Second
+
+// startWithLeader gates Start's AddTask/runEntries cycle behind leader
+// election: it blocks on cfg.Leader.Acquire, keeps the lease alive with a
+// background renewer at ttl/3, and re-enters acquisition whenever the lease
+// is lost. The caller (Start) must already hold the stateStarting claim for
+// the duration of this call, including while blocked in Acquire, so that a
+// concurrent Start/Run is rejected instead of racing into its own election.
+func (e *Engine) startWithLeader(ctx context.Context, cfg *Config, task TaskInterface, opts TaskOptions) error {
+	ttl := cfg.LeaderTTL
+	if ttl <= 0 {
+		ttl = defaultLeaderTTL
+	}
+	key := cfg.Name
+
+	for {
+		lease, err := cfg.Leader.Acquire(ctx, key, ttl)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			e.log.Warnf("[%s] failed to acquire leader lock, retrying: %v", cfg.Name, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(ttl / 3):
+			}
+			continue
+		}
+		e.log.Debugf("[%s] acquired leader lock", cfg.Name)
+
+		runCtx, cancel := context.WithCancel(ctx)
+		renewErr := make(chan error, 1)
+		go renewLease(runCtx, lease, ttl, renewErr)
+
+		if _, err := e.AddTask(cfg.Name, task, opts); err != nil {
+			cancel()
+			return err
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- e.runEntries(runCtx) }()
+
+		select {
+		case rErr := <-renewErr:
+			e.log.Errorf("[%s] lost leader lock, re-electing: %v", cfg.Name, rErr)
+			cancel()
+			<-done
+			e.RemoveTask(cfg.Name)
+			_ = lease.Release(context.Background())
+			continue
+		case err := <-done:
+			cancel()
+			_ = lease.Release(context.Background())
+			return err
+		}
+	}
+}
+
+// renewLease renews lease every ttl/3 until ctx is canceled or a renewal
+// fails, in which case the failure is sent on errCh.
+func renewLease(ctx context.Context, lease Lease, ttl time.Duration, errCh chan<- error) {
+	interval := ttl / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := lease.Renew(ctx); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package etcd implements regular.Locker on top of an etcd lease plus a
+// concurrency.Election, so that campaigning replicas block until they win
+// the election and lose it automatically if the lease expires.
+package etcd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+
+	"github.com/99nil/go/regular"
+)
+
+// ErrSessionExpired is returned by Renew once the underlying etcd session
+// (and therefore the lease backing our leadership) has ended.
+var ErrSessionExpired = errors.New("etcd: session expired")
+
+// Locker implements regular.Locker against a single etcd client.
+type Locker struct {
+	Client *clientv3.Client
+}
+
+// New returns a Locker backed by client.
+func New(client *clientv3.Client) *Locker {
+	return &Locker{Client: client}
+}
+
+// Acquire implements regular.Locker. It blocks until ctx is canceled or the
+// campaign for key succeeds.
+func (l *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (regular.Lease, error) {
+	session, err := concurrency.NewSession(l.Client, concurrency.WithTTL(int(ttl.Seconds())))
+	if err != nil {
+		return nil, err
+	}
+
+	election := concurrency.NewElection(session, key)
+	if err := election.Campaign(ctx, ""); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &lease{session: session, election: election}, nil
+}
+
+type lease struct {
+	session  *concurrency.Session
+	election *concurrency.Election
+}
+
+// Renew does not itself extend the underlying etcd lease: etcd keeps the
+// lease alive in the background for the life of the session, so Renew only
+// checks that the session (and therefore the lease) is still valid.
+func (le *lease) Renew(ctx context.Context) error {
+	select {
+	case <-le.session.Done():
+		return ErrSessionExpired
+	default:
+		return nil
+	}
+}
+
+func (le *lease) Release(ctx context.Context) error {
+	if err := le.election.Resign(ctx); err != nil {
+		le.session.Close()
+		return err
+	}
+	return le.session.Close()
+}
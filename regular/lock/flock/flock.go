@@ -0,0 +1,89 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+// Package flock implements regular.Locker using an advisory file lock
+// (flock(2)), for single-host deployments that want the same Locker
+// interface as the redis/etcd adapters without an external dependency.
+package flock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/99nil/go/regular"
+)
+
+// ErrNotAcquired is returned by Acquire when another process already holds
+// the lock file.
+var ErrNotAcquired = errors.New("flock: lock not acquired")
+
+// Locker implements regular.Locker by flock(2)-ing a file named after the
+// lock key inside Dir.
+type Locker struct {
+	// Dir is the directory lock files are created in. Defaults to os.TempDir().
+	Dir string
+}
+
+// New returns a Locker that creates lock files under dir.
+func New(dir string) *Locker {
+	return &Locker{Dir: dir}
+}
+
+// Acquire implements regular.Locker. It makes a single non-blocking attempt:
+// if the file is already locked, it returns ErrNotAcquired immediately
+// instead of waiting for it to free up. ttl is accepted for interface
+// compatibility but unused: a held flock is released as soon as the holding
+// process exits or calls Release, with no separate expiry.
+func (l *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (regular.Lease, error) {
+	dir := l.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, key+".lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrNotAcquired
+		}
+		return nil, err
+	}
+	return &lease{f: f}, nil
+}
+
+type lease struct {
+	f *os.File
+}
+
+// Renew is a no-op: a held flock never expires on its own.
+func (le *lease) Renew(ctx context.Context) error {
+	return nil
+}
+
+func (le *lease) Release(ctx context.Context) error {
+	if err := syscall.Flock(int(le.f.Fd()), syscall.LOCK_UN); err != nil {
+		le.f.Close()
+		return err
+	}
+	return le.f.Close()
+}
@@ -0,0 +1,63 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics receives observability events from a task's execution. All
+// methods must be safe for concurrent use. Implementations should embed
+// NopMetrics so they only need to override the events they care about.
+type Metrics interface {
+	// RunStart is called when task.Run begins; the returned func must be
+	// called once it returns, with the outcome and how long it took.
+	RunStart(task string) func(err error, d time.Duration)
+	// Backoff records the delay slept after a failed run, so operators can
+	// alert on a task stuck in a backoff loop.
+	Backoff(task string, sleep time.Duration)
+	// WindowOpen/WindowClose record a Periods window opening and closing,
+	// so operators can alert on a task running outside its allowed window.
+	WindowOpen(task string)
+	WindowClose(task string)
+}
+
+// NopMetrics implements Metrics with no-ops.
+type NopMetrics struct{}
+
+func (NopMetrics) RunStart(task string) func(error, time.Duration) {
+	return func(error, time.Duration) {}
+}
+
+func (NopMetrics) Backoff(task string, sleep time.Duration) {}
+
+func (NopMetrics) WindowOpen(task string) {}
+
+func (NopMetrics) WindowClose(task string) {}
+
+// Tracer starts a trace span around each task.Run(ctx) call.
+type Tracer interface {
+	// Start returns a context carrying the new span, and a func that must
+	// be called with the run's outcome once it ends the span.
+	Start(ctx context.Context, task string) (context.Context, func(err error))
+}
+
+func metricsOrNop(m Metrics) Metrics {
+	if m == nil {
+		return NopMetrics{}
+	}
+	return m
+}
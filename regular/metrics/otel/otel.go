@@ -0,0 +1,157 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otel implements regular.Metrics with OpenTelemetry metric
+// instruments and regular.Tracer with an OpenTelemetry tracer, both labeled
+// by task name via the "task" attribute.
+package otel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/99nil/go/regular"
+)
+
+const instrumentationName = "github.com/99nil/go/regular"
+
+// Metrics implements regular.Metrics on top of an OTel MeterProvider.
+type Metrics struct {
+	runs        metric.Int64Counter
+	success     metric.Int64Counter
+	failure     metric.Int64Counter
+	duration    metric.Float64Histogram
+	inFlight    metric.Int64UpDownCounter
+	backoffTime metric.Float64Histogram
+	windowOpen  metric.Int64UpDownCounter
+
+	lastSuccessMu  sync.Mutex
+	lastSuccessUTS map[string]float64
+}
+
+// NewMetrics creates a Metrics using instruments from provider.
+func NewMetrics(provider metric.MeterProvider) (*Metrics, error) {
+	meter := provider.Meter(instrumentationName)
+
+	var err error
+	m := &Metrics{lastSuccessUTS: make(map[string]float64)}
+	if m.runs, err = meter.Int64Counter("regular.runs"); err != nil {
+		return nil, err
+	}
+	if m.success, err = meter.Int64Counter("regular.run_success"); err != nil {
+		return nil, err
+	}
+	if m.failure, err = meter.Int64Counter("regular.run_failure"); err != nil {
+		return nil, err
+	}
+	if m.duration, err = meter.Float64Histogram("regular.run_duration_seconds"); err != nil {
+		return nil, err
+	}
+	if m.inFlight, err = meter.Int64UpDownCounter("regular.in_flight"); err != nil {
+		return nil, err
+	}
+	if m.backoffTime, err = meter.Float64Histogram("regular.backoff_sleep_seconds"); err != nil {
+		return nil, err
+	}
+	if m.windowOpen, err = meter.Int64UpDownCounter("regular.window_open"); err != nil {
+		return nil, err
+	}
+	lastSuccess, err := meter.Float64ObservableGauge(
+		"regular.last_success_unixtime",
+		metric.WithDescription("Unix timestamp of the task's last successful run; alert on time() - this exceeding a threshold."),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		m.lastSuccessMu.Lock()
+		defer m.lastSuccessMu.Unlock()
+		for task, uts := range m.lastSuccessUTS {
+			o.ObserveFloat64(lastSuccess, uts, metric.WithAttributes(attribute.String("task", task)))
+		}
+		return nil
+	}, lastSuccess); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RunStart implements regular.Metrics.
+func (m *Metrics) RunStart(task string) func(err error, d time.Duration) {
+	ctx := context.Background()
+	attr := metric.WithAttributes(attribute.String("task", task))
+
+	m.runs.Add(ctx, 1, attr)
+	m.inFlight.Add(ctx, 1, attr)
+
+	return func(err error, d time.Duration) {
+		m.inFlight.Add(ctx, -1, attr)
+		m.duration.Record(ctx, d.Seconds(), attr)
+		if err != nil {
+			m.failure.Add(ctx, 1, attr)
+			return
+		}
+		m.success.Add(ctx, 1, attr)
+		m.lastSuccessMu.Lock()
+		m.lastSuccessUTS[task] = float64(time.Now().Unix())
+		m.lastSuccessMu.Unlock()
+	}
+}
+
+// Backoff implements regular.Metrics.
+func (m *Metrics) Backoff(task string, sleep time.Duration) {
+	m.backoffTime.Record(context.Background(), sleep.Seconds(), metric.WithAttributes(attribute.String("task", task)))
+}
+
+// WindowOpen implements regular.Metrics.
+func (m *Metrics) WindowOpen(task string) {
+	m.windowOpen.Add(context.Background(), 1, metric.WithAttributes(attribute.String("task", task)))
+}
+
+// WindowClose implements regular.Metrics.
+func (m *Metrics) WindowClose(task string) {
+	m.windowOpen.Add(context.Background(), -1, metric.WithAttributes(attribute.String("task", task)))
+}
+
+var _ regular.Metrics = (*Metrics)(nil)
+
+// Tracer implements regular.Tracer on top of an OTel TracerProvider.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates a Tracer using provider.
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(instrumentationName)}
+}
+
+// Start implements regular.Tracer.
+func (t *Tracer) Start(ctx context.Context, task string) (context.Context, func(err error)) {
+	ctx, span := t.tracer.Start(ctx, "regular.task.run", trace.WithAttributes(attribute.String("task", task)))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+var _ regular.Tracer = (*Tracer)(nil)
@@ -0,0 +1,134 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prom implements regular.Metrics with Prometheus collectors, all
+// labeled by task name so a single registry can track every task hosted by
+// an Engine.
+package prom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/99nil/go/regular"
+)
+
+// Metrics implements regular.Metrics by recording to a set of Prometheus
+// collectors registered under namespace "regular".
+type Metrics struct {
+	runsTotal         *prometheus.CounterVec
+	successTotal      *prometheus.CounterVec
+	failureTotal      *prometheus.CounterVec
+	runDuration       *prometheus.HistogramVec
+	inFlight          *prometheus.GaugeVec
+	lastSuccessUnixTS *prometheus.GaugeVec
+	backoffSleep      *prometheus.HistogramVec
+	windowOpen        *prometheus.GaugeVec
+}
+
+// New creates a Metrics and registers its collectors with reg. Pass
+// prometheus.DefaultRegisterer to use the global registry.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		runsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "regular",
+			Name:      "runs_total",
+			Help:      "Total number of task.Run calls.",
+		}, []string{"task"}),
+		successTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "regular",
+			Name:      "run_success_total",
+			Help:      "Total number of successful task.Run calls.",
+		}, []string{"task"}),
+		failureTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "regular",
+			Name:      "run_failure_total",
+			Help:      "Total number of failed task.Run calls.",
+		}, []string{"task"}),
+		runDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "regular",
+			Name:      "run_duration_seconds",
+			Help:      "Duration of task.Run calls.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"task"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "regular",
+			Name:      "in_flight",
+			Help:      "Number of task.Run calls currently executing.",
+		}, []string{"task"}),
+		lastSuccessUnixTS: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "regular",
+			Name:      "last_success_unixtime",
+			Help:      "Unix timestamp of the task's last successful run; alert on time() - this exceeding a threshold.",
+		}, []string{"task"}),
+		backoffSleep: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "regular",
+			Name:      "backoff_sleep_seconds",
+			Help:      "Sleep duration applied after a failed run.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"task"}),
+		windowOpen: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "regular",
+			Name:      "window_open",
+			Help:      "1 while the task is inside an allowed run window, 0 otherwise; alert on a run happening while this is 0.",
+		}, []string{"task"}),
+	}
+
+	reg.MustRegister(
+		m.runsTotal,
+		m.successTotal,
+		m.failureTotal,
+		m.runDuration,
+		m.inFlight,
+		m.lastSuccessUnixTS,
+		m.backoffSleep,
+		m.windowOpen,
+	)
+	return m
+}
+
+// RunStart implements regular.Metrics.
+func (m *Metrics) RunStart(task string) func(err error, d time.Duration) {
+	m.runsTotal.WithLabelValues(task).Inc()
+	m.inFlight.WithLabelValues(task).Inc()
+
+	return func(err error, d time.Duration) {
+		m.inFlight.WithLabelValues(task).Dec()
+		m.runDuration.WithLabelValues(task).Observe(d.Seconds())
+		if err != nil {
+			m.failureTotal.WithLabelValues(task).Inc()
+			return
+		}
+		m.successTotal.WithLabelValues(task).Inc()
+		m.lastSuccessUnixTS.WithLabelValues(task).Set(float64(time.Now().Unix()))
+	}
+}
+
+// Backoff implements regular.Metrics.
+func (m *Metrics) Backoff(task string, sleep time.Duration) {
+	m.backoffSleep.WithLabelValues(task).Observe(sleep.Seconds())
+}
+
+// WindowOpen implements regular.Metrics.
+func (m *Metrics) WindowOpen(task string) {
+	m.windowOpen.WithLabelValues(task).Set(1)
+}
+
+// WindowClose implements regular.Metrics.
+func (m *Metrics) WindowClose(task string) {
+	m.windowOpen.WithLabelValues(task).Set(0)
+}
+
+var _ regular.Metrics = (*Metrics)(nil)
@@ -18,7 +18,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/99nil/gopkg/logger"
 )
@@ -49,16 +49,24 @@ func NewWithLogger(cfg *Config, log logger.UniversalInterface) (*Engine, error)
 	return e, nil
 }
 
+// Engine hosts one or more named tasks, each driven by its own Periods or
+// Schedule, and reconciles them independently and concurrently.
 type Engine struct {
 	m sync.Mutex
 
 	cfg *Config
 	log logger.UniversalInterface
 
-	cancel context.CancelFunc
-	stopCh chan struct{}
+	tasks map[string]*taskEntry
+
+	state     int32 // atomic engineState
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
 }
 
+// SetConfig installs cfg as the engine's legacy single-task configuration,
+// consumed by Start. It has no effect on tasks already registered via
+// AddTask.
 func (e *Engine) SetConfig(cfg *Config) error {
 	if cfg == nil {
 		return nil
@@ -84,127 +92,57 @@ func (e *Engine) GetConfig() *Config {
 	return e.cfg
 }
 
-func (e *Engine) Shutdown() {
-	close(e.stopCh)
-}
-
-func (e *Engine) Start(ctx context.Context, task TaskInterface) error {
-	e.stopCh = make(chan struct{})
-
-	for {
-		second := time.Now().Second()
-		if second == 0 {
-			break
-		}
-		sleepInterval := 60 - second
-		e.log.Warnf("[%s] The current seconds is not 0, need to wait for %ds to start the automatic assistant", e.cfg.Name, sleepInterval)
-		time.Sleep(time.Duration(sleepInterval) * time.Second)
-	}
-
-	if len(e.GetConfig().Periods) == 0 {
-		return e.run(ctx, task)
+// Shutdown stops a running engine, blocking future fires and canceling any
+// in-flight task runs. It returns ErrAlreadyStopped if the engine is not
+// currently running, either because it was never started or Shutdown was
+// already called; callers that need to wait for tasks to actually exit
+// should follow it with Wait.
+func (e *Engine) Shutdown() error {
+	if !atomic.CompareAndSwapInt32(&e.state, int32(stateRunning), int32(stateStopping)) {
+		return ErrAlreadyStopped
 	}
 
-	currentStartHour, currentStartMinute := -1, -1
-	ticker := time.NewTicker(time.Minute)
-	for {
-		if e.cancel == nil {
-			e.log.Debugf("[%s] Start mission reconnaissance", e.cfg.Name)
-		}
-		now := time.Now()
-		hour := now.Hour()
-		minute := now.Minute()
-
-		for _, v := range e.GetConfig().Periods {
-			if currentStartHour > -1 && (currentStartHour != v.startHour || currentStartMinute != v.startMinute) {
-				continue
-			}
-
-			start, end := CheckTime(v.startHour, v.startMinute, v.endHour, v.endMinute, hour, minute)
-			if start && !end && currentStartHour != v.startHour {
-				currentStartHour = v.startHour
-				currentStartMinute = v.startMinute
-
-				ctx, e.cancel = context.WithCancel(ctx)
-				go func() {
-					if err := e.run(ctx, task); err != nil {
-						e.log.Errorf("[%s] Execution ends with error: %v", e.cfg.Name, err)
-					}
-					e.log.Debugf("[%s] The execution of the current time period is over, please wait for the next time period", e.cfg.Name)
-				}()
-				break
-			}
-			if start && end && e.cancel != nil {
-				e.cancel()
-				e.cancel = nil
-			}
-		}
+	e.m.Lock()
+	stopCh := e.stopCh
+	e.m.Unlock()
+	close(stopCh)
+	return nil
+}
 
-		select {
-		case <-e.stopCh:
-			if e.cancel != nil {
-				e.cancel()
-			}
-			e.log.Debugf("[%s] task stopped", e.cfg.Name)
-			return nil
-		case <-ticker.C:
-		}
+// Start runs a single task under the engine's legacy Config. It is a thin
+// wrapper that registers task as an anonymous task (named after cfg.Name)
+// and blocks until the engine is shut down or ctx is canceled; with
+// cfg.Leader set, failed Acquire calls are retried rather than returned.
+// It claims the engine
+// for the entire call, including the time spent blocked in leader election,
+// so it returns ErrAlreadyStarted if the engine is already started by a
+// concurrent Start or Run, rather than only once a leader is held.
+func (e *Engine) Start(ctx context.Context, task TaskInterface) error {
+	if !atomic.CompareAndSwapInt32(&e.state, int32(stateStopped), int32(stateStarting)) {
+		return ErrAlreadyStarted
 	}
-}
 
-func (e *Engine) run(ctx context.Context, task TaskInterface) error {
 	cfg := e.GetConfig()
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		if err := task.Run(ctx); err != nil {
-			if cfg.FailInterval < 0 {
-				return err
-			}
-			e.log.Errorf("[%s] Execution ends with error: %v", e.cfg.Name, err)
-			e.log.Warnf("[%s] Will continue after %dms", e.cfg.Name, cfg.FailInterval)
-			fmt.Println()
-			time.Sleep(time.Duration(cfg.FailInterval) * time.Millisecond)
-			continue
-		}
-		cfg = e.GetConfig()
-
-		if cfg.SuccessInterval < 0 {
-			return nil
-		}
-		e.log.Debugf("[%s] Executed successfully, will continue after %dms", e.cfg.Name, cfg.SuccessInterval)
-		time.Sleep(time.Duration(cfg.SuccessInterval) * time.Millisecond)
+	opts := TaskOptions{
+		Periods:         cfg.Periods,
+		Schedule:        cfg.Schedule,
+		FailInterval:    cfg.FailInterval,
+		SuccessInterval: cfg.SuccessInterval,
+		Backoff:         cfg.Backoff,
+		Metrics:         cfg.Metrics,
+		Tracer:          cfg.Tracer,
 	}
-}
 
-func CheckTime(startHour, startMinute, endHour, endMinute, currentHour, currentMinute int) (start bool, end bool) {
-	startTime := time.Date(0, 0, 0, startHour, startMinute, 0, 0, time.Local)
-	endTime := time.Date(0, 0, 0, endHour, endMinute, 0, 0, time.Local)
-	currentTime := time.Date(0, 0, 0, currentHour, currentMinute, 0, 0, time.Local)
-
-	since := startTime.Sub(endTime)
-	if since == 0 {
-		start = true
-		return
-	} else if since < 0 {
-		if currentTime.Sub(startTime) >= 0 {
-			start = true
-		}
-		if currentTime.Sub(endTime) >= 0 {
-			end = true
-		}
-	} else {
-		if currentTime.Sub(endTime) >= 0 {
-			end = true
-		}
-		if currentTime.Sub(startTime) >= 0 || !end {
-			start = true
-			end = false
-		}
+	if cfg.Leader != nil {
+		err := e.startWithLeader(ctx, cfg, task, opts)
+		atomic.StoreInt32(&e.state, int32(stateStopped))
+		return err
+	}
+	if _, err := e.AddTask(cfg.Name, task, opts); err != nil {
+		atomic.StoreInt32(&e.state, int32(stateStopped))
+		return err
 	}
-	return
+	err := e.runEntries(ctx)
+	atomic.StoreInt32(&e.state, int32(stateStopped))
+	return err
 }
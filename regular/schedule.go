@@ -0,0 +1,331 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheduler computes the next instant a scheduled task should fire.
+// Implementations must be safe for concurrent use.
+type Scheduler interface {
+	// Next returns the next instant strictly after "after" at which the
+	// schedule fires.
+	Next(after time.Time) time.Time
+}
+
+// cronField is the parsed representation of a single cron field: either an
+// explicit bitmask of allowed values, or a "*/n" step evaluated against the
+// field's own value.
+type cronField struct {
+	mask uint64 // bit i set means value i is allowed
+	step int    // set for "*/n", 0 disables step matching
+}
+
+func (f cronField) match(v int) bool {
+	if f.step > 0 {
+		return v%f.step == 0
+	}
+	return f.mask&(1<<uint(v)) != 0
+}
+
+var cronFieldBounds = [6][2]int{
+	{0, 59}, // seconds
+	{0, 59}, // minutes
+	{0, 23}, // hours
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week, 0 = Sunday
+}
+
+// CronSchedule parses a 5- or 6-field cron expression, or one of the
+// shortcuts "@daily", "@hourly", "@weekly", "@monthly", "@yearly" or
+// "@every <duration>" in place of one, and computes the next matching instant in its own
+// time zone. A 6-field expression leads with the seconds field; a 5-field
+// expression runs at second 0.
+type CronSchedule struct {
+	expr string
+	loc  *time.Location
+
+	fields [6]cronField // sec, min, hour, dom, month, dow
+	every  time.Duration
+
+	mu       sync.Mutex
+	lastFire time.Time
+}
+
+// NewCronSchedule parses expr and resolves timeZone via time.LoadLocation.
+// An empty timeZone means the local zone.
+func NewCronSchedule(expr, timeZone string) (*CronSchedule, error) {
+	loc, err := loadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("load time zone %q: %v", timeZone, err)
+	}
+	cs := &CronSchedule{expr: expr, loc: loc}
+	if err := cs.parse(expr); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+func loadLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(tz)
+}
+
+func (cs *CronSchedule) parse(expr string) error {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case expr == "@yearly" || expr == "@annually":
+		expr = "0 0 0 1 1 *"
+	case expr == "@monthly":
+		expr = "0 0 0 1 * *"
+	case expr == "@weekly":
+		expr = "0 0 0 * * 0"
+	case expr == "@daily" || expr == "@midnight":
+		expr = "0 0 0 * * *"
+	case expr == "@hourly":
+		expr = "0 0 * * * *"
+	case strings.HasPrefix(expr, "@every "):
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return fmt.Errorf("invalid @every expression %q: %v", expr, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("invalid @every expression %q: duration must be positive", expr)
+		}
+		cs.every = d
+		return nil
+	}
+
+	parts := strings.Fields(expr)
+	switch len(parts) {
+	case 5:
+		parts = append([]string{"0"}, parts...)
+	case 6:
+		// already includes a seconds field
+	default:
+		return fmt.Errorf("invalid cron expression %q: expected 5 or 6 fields, got %d", expr, len(parts))
+	}
+
+	for i, part := range parts {
+		f, err := parseCronField(part, cronFieldBounds[i][0], cronFieldBounds[i][1])
+		if err != nil {
+			return fmt.Errorf("invalid cron expression %q: field %d: %v", expr, i, err)
+		}
+		cs.fields[i] = f
+	}
+	return nil
+}
+
+func parseCronField(field string, min, max int) (cronField, error) {
+	if field == "*" {
+		return cronField{mask: fullMask(min, max)}, nil
+	}
+	if strings.HasPrefix(field, "*/") {
+		step, err := strconv.Atoi(field[2:])
+		if err != nil || step <= 0 {
+			return cronField{}, fmt.Errorf("invalid step %q", field)
+		}
+		return cronField{step: step}, nil
+	}
+
+	var f cronField
+	for _, item := range strings.Split(field, ",") {
+		lo, hi := min, max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			v, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return cronField{}, fmt.Errorf("invalid value %q", item)
+			}
+			lo, hi = v, v
+			if len(bounds) == 2 {
+				hi, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return cronField{}, fmt.Errorf("invalid range %q", item)
+				}
+			}
+		}
+		for v := lo; v <= hi; v++ {
+			f.mask |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+func fullMask(min, max int) uint64 {
+	var mask uint64
+	for v := min; v <= max; v++ {
+		mask |= 1 << uint(v)
+	}
+	return mask
+}
+
+// yearSearchLimit bounds how many years into the future nextMatch will look
+// before giving up, guarding against expressions that can never match (e.g.
+// Feb 30).
+const yearSearchLimit = 5
+
+// Next returns the next instant strictly after "after", computed in the
+// schedule's own time zone and converted back to after's location. It
+// advances past the DST spring-forward gap automatically, and relies on
+// lastFire to fire only once during the DST fall-back duplicate hour.
+func (cs *CronSchedule) Next(after time.Time) time.Time {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.every > 0 {
+		next := after.Add(cs.every)
+		cs.lastFire = next
+		return next
+	}
+
+	candidate := cs.nextMatch(after.In(cs.loc))
+	for !candidate.IsZero() && sameWallClock(candidate, cs.lastFire, cs.loc) {
+		candidate = cs.nextMatch(candidate)
+	}
+	if candidate.IsZero() {
+		return time.Time{}
+	}
+	cs.lastFire = candidate
+	return candidate.In(after.Location())
+}
+
+// nextMatch returns the next instant strictly after from that satisfies
+// every cron field, searching field-by-field (month, then day-of-month
+// combined with day-of-week, then hour, minute, second) instead of scanning
+// one second at a time, so expressions with large gaps between matches (or
+// that never match at all, like "31 2" for February) resolve in a handful of
+// steps rather than a multi-year scan. It gives up and returns the zero Time
+// once the search passes yearSearchLimit years into the future.
+func (cs *CronSchedule) nextMatch(from time.Time) time.Time {
+	t := from.Truncate(time.Second).Add(time.Second)
+	yearLimit := t.Year() + yearSearchLimit
+	added := false
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for !cs.fields[4].match(int(t.Month())) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !(cs.fields[3].match(t.Day()) && cs.fields[5].match(int(t.Weekday()))) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+	for !cs.fields[2].match(t.Hour()) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+	for !cs.fields[1].match(t.Minute()) {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+	for !cs.fields[0].match(t.Second()) {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+// sameWallClock reports whether a and b, converted into loc, display the
+// same Y/M/D H:M:S. During a DST fall-back, the same wall-clock instant
+// occurs twice as two distinct absolute instants an hour apart; comparing
+// wall-clock fields instead of absolute equality is what lets Next suppress
+// the repeat and fire only once for that hour. It returns false if b is the
+// zero Time, since lastFire starts zero and shouldn't suppress anything.
+func sameWallClock(a, b time.Time, loc *time.Location) bool {
+	if b.IsZero() {
+		return false
+	}
+	a, b = a.In(loc), b.In(loc)
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd &&
+		a.Hour() == b.Hour() && a.Minute() == b.Minute() && a.Second() == b.Second()
+}
+
+// PeriodScheduler adapts the legacy daily Periods windows to the Scheduler
+// interface, so callers that want a single fire at each window's start
+// (instead of the Engine's built-in Periods loop, which keeps re-running the
+// task for as long as a window stays open) can do so explicitly.
+type PeriodScheduler struct {
+	Periods []*Period
+	Loc     *time.Location
+}
+
+// Next returns the next instant at which one of ps.Periods opens.
+func (ps *PeriodScheduler) Next(after time.Time) time.Time {
+	loc := ps.Loc
+	if loc == nil {
+		loc = time.Local
+	}
+	local := after.In(loc)
+
+	var best time.Time
+	for _, p := range ps.Periods {
+		start := time.Date(local.Year(), local.Month(), local.Day(), p.startHour, p.startMinute, 0, 0, loc)
+		if !start.After(local) {
+			start = start.AddDate(0, 0, 1)
+		}
+		if best.IsZero() || start.Before(best) {
+			best = start
+		}
+	}
+	if best.IsZero() {
+		return best
+	}
+	return best.In(after.Location())
+}
@@ -0,0 +1,148 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	cases := []struct {
+		name  string
+		expr  string
+		after string
+		want  string
+	}{
+		{
+			name:  "five field runs at second zero",
+			expr:  "30 2 * * *",
+			after: "2024-01-01T00:00:00Z",
+			want:  "2024-01-01T02:30:00Z",
+		},
+		{
+			name:  "six field with explicit seconds",
+			expr:  "15 30 2 * * *",
+			after: "2024-01-01T00:00:00Z",
+			want:  "2024-01-01T02:30:15Z",
+		},
+		{
+			name:  "step field",
+			expr:  "*/15 * * * *",
+			after: "2024-01-01T00:01:00Z",
+			want:  "2024-01-01T00:15:00Z",
+		},
+		{
+			name:  "rolls into the next month",
+			expr:  "0 0 0 1 * *",
+			after: "2024-01-15T00:00:00Z",
+			want:  "2024-02-01T00:00:00Z",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cs, err := NewCronSchedule(tc.expr, "UTC")
+			if err != nil {
+				t.Fatalf("NewCronSchedule(%q): %v", tc.expr, err)
+			}
+			after, err := time.Parse(time.RFC3339, tc.after)
+			if err != nil {
+				t.Fatalf("parse after: %v", err)
+			}
+			want, err := time.Parse(time.RFC3339, tc.want)
+			if err != nil {
+				t.Fatalf("parse want: %v", err)
+			}
+			if got := cs.Next(after); !got.Equal(want) {
+				t.Errorf("Next(%v) = %v, want %v", after, got, want)
+			}
+		})
+	}
+}
+
+// TestCronScheduleNextNeverMatches guards against an impossible expression
+// (a day-of-month no month can ever have) hanging or panicking instead of
+// reporting "never fires" via a zero Time.
+func TestCronScheduleNextNeverMatches(t *testing.T) {
+	cs, err := NewCronSchedule("0 0 0 31 2 *", "UTC")
+	if err != nil {
+		t.Fatalf("NewCronSchedule: %v", err)
+	}
+	after, _ := time.Parse(time.RFC3339, "2024-01-01T00:00:00Z")
+	if got := cs.Next(after); !got.IsZero() {
+		t.Errorf("Next() = %v, want zero Time for an impossible expression", got)
+	}
+}
+
+// TestCronScheduleNextLeapDayFromNonLeapYear guards against a rare but legal
+// expression (leap-day-only) taking a multi-second-per-step scan to resolve;
+// it should jump straight to the next February 29th.
+func TestCronScheduleNextLeapDayFromNonLeapYear(t *testing.T) {
+	cs, err := NewCronSchedule("0 0 0 29 2 *", "UTC")
+	if err != nil {
+		t.Fatalf("NewCronSchedule: %v", err)
+	}
+	after, _ := time.Parse(time.RFC3339, "2023-01-01T00:00:00Z")
+	want, _ := time.Parse(time.RFC3339, "2024-02-29T00:00:00Z")
+	if got := cs.Next(after); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+}
+
+func TestCronScheduleEvery(t *testing.T) {
+	cs, err := NewCronSchedule("@every 1h", "")
+	if err != nil {
+		t.Fatalf("NewCronSchedule: %v", err)
+	}
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := after.Add(time.Hour)
+	if got := cs.Next(after); !got.Equal(want) {
+		t.Errorf("Next(%v) = %v, want %v", after, got, want)
+	}
+}
+
+// TestCronScheduleNextDSTFallBackDedup guards against the DST fall-back
+// duplicate wall-clock hour causing a daily schedule to fire twice: the same
+// wall-clock instant occurs at two different absolute instants an hour
+// apart, and Next must suppress the second one via lastFire.
+func TestCronScheduleNextDSTFallBackDedup(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+
+	// DST ends 2023-11-05 02:00 America/New_York; 1:30 AM occurs twice.
+	cs, err := NewCronSchedule("30 1 * * *", "America/New_York")
+	if err != nil {
+		t.Fatalf("NewCronSchedule: %v", err)
+	}
+
+	before := time.Date(2023, 11, 5, 0, 0, 0, 0, loc)
+	first := cs.Next(before)
+	if first.IsZero() {
+		t.Fatalf("first Next() = zero, want a match")
+	}
+
+	second := cs.Next(first)
+	if second.Equal(first) {
+		t.Fatalf("second Next() = %v, want it to skip the duplicate wall-clock hour", second)
+	}
+	// The next fire should be the following day's 1:30 AM, not the
+	// duplicate occurrence an hour after first.
+	if second.Sub(first) < 23*time.Hour {
+		t.Errorf("second Next() = %v, fired too soon after %v (duplicate hour not suppressed)", second, first)
+	}
+}
@@ -0,0 +1,486 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TaskOptions configures a task registered with Engine.AddTask.
+type TaskOptions struct {
+	// Periods restricts execution to one or more daily time windows,
+	// compared in the local time zone. Ignored once Schedule is set.
+	Periods []*Period
+	// Schedule, when set, takes precedence over Periods and drives the
+	// task on a cron-style schedule instead of a fixed daily window.
+	Schedule Scheduler
+	// FailInterval is the delay in milliseconds before retrying after a
+	// failed execution. A negative value stops the task on the first error.
+	// Under Schedule mode there is no internal retry loop to stop: a
+	// negative value is instead treated as no extra delay, and the fire
+	// after a failure is paced out by this delay (or Backoff, if set)
+	// instead of FailInterval's own retry.
+	FailInterval int64
+	// SuccessInterval is the delay in milliseconds before the next
+	// execution after a successful one. A negative value runs the task
+	// exactly once per period/schedule fire. Ignored under Schedule mode,
+	// which always runs exactly once per fire.
+	SuccessInterval int64
+	// Backoff, when set, replaces the flat FailInterval delay with a
+	// computed one based on the number of consecutive failures. Under
+	// Schedule mode this paces out the fires that follow a failure instead
+	// of an internal retry.
+	Backoff Backoff
+	// MaxConsecutiveFailures logs once this many consecutive failures have
+	// been observed. Under Periods and continuous mode it also trips a
+	// circuit breaker that stops retrying and returns: under Periods the
+	// outer period loop retries at the next window instead of spinning
+	// forever, while a continuous task stays stopped; under Schedule mode there is no internal loop to stop, so it is
+	// purely informational there and the schedule keeps firing, still
+	// paced by FailInterval/Backoff. Zero disables both.
+	MaxConsecutiveFailures int
+	// MaxConcurrency caps how many overlapping runs of this task may be in
+	// flight at once under Schedule mode; a fire that would exceed the cap
+	// is skipped rather than queued. Defaults to 1. Ignored under Periods
+	// and continuous mode, which only ever run one instance of the task.
+	MaxConcurrency int
+	// Singleton, when true, forces MaxConcurrency to 1 regardless of its
+	// configured value.
+	Singleton bool
+	// Metrics, when set, records run counts, durations and window
+	// transitions for the task. Defaults to NopMetrics.
+	Metrics Metrics
+	// Tracer, when set, wraps each task.Run(ctx) call in a span.
+	Tracer Tracer
+}
+
+func (o *TaskOptions) parse() error {
+	for k, v := range o.Periods {
+		if err := v.Parse(); err != nil {
+			return fmt.Errorf("analysis time period %d failed: %v", k, err)
+		}
+	}
+	if o.MaxConcurrency <= 0 {
+		o.MaxConcurrency = 1
+	}
+	return nil
+}
+
+// TaskHandle references a task registered with Engine.AddTask.
+type TaskHandle struct {
+	name string
+	e    *Engine
+}
+
+// Name returns the task's registered name.
+func (h TaskHandle) Name() string {
+	return h.name
+}
+
+// Remove stops and unregisters the task, blocking until it has exited.
+func (h TaskHandle) Remove() {
+	h.e.RemoveTask(h.name)
+}
+
+// taskEntry is the engine's internal bookkeeping for a registered task.
+type taskEntry struct {
+	name string
+	task TaskInterface
+	opts TaskOptions
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	sem      chan struct{}  // bounds concurrent runs under Schedule mode, sized by effectiveConcurrency
+	wg       sync.WaitGroup // tracks in-flight concurrent runs dispatched by runEntryScheduled
+	inFlight int32          // atomic count of concurrently running entry.task.Run calls
+
+	failures  int32 // atomic count of consecutive failures across Schedule fires
+	nextRetry int64 // atomic UnixNano; Schedule fires before this are skipped after a failure
+}
+
+// effectiveConcurrency returns how many concurrent runs of the task
+// opts allows: 1 if Singleton, otherwise MaxConcurrency.
+func (o TaskOptions) effectiveConcurrency() int {
+	if o.Singleton {
+		return 1
+	}
+	return o.MaxConcurrency
+}
+
+// AddTask registers task under name with the given options. If the engine
+// is already running (Run has been called), the task starts immediately;
+// otherwise it starts the next time Run is called.
+func (e *Engine) AddTask(name string, task TaskInterface, opts TaskOptions) (TaskHandle, error) {
+	if name == "" {
+		return TaskHandle{}, fmt.Errorf("task name must not be empty")
+	}
+	if err := opts.parse(); err != nil {
+		return TaskHandle{}, err
+	}
+
+	e.m.Lock()
+	if e.tasks == nil {
+		e.tasks = make(map[string]*taskEntry)
+	}
+	if _, ok := e.tasks[name]; ok {
+		e.m.Unlock()
+		return TaskHandle{}, fmt.Errorf("task %q is already registered", name)
+	}
+	entry := &taskEntry{name: name, task: task, opts: opts}
+	entry.sem = make(chan struct{}, opts.effectiveConcurrency())
+	e.tasks[name] = entry
+	e.m.Unlock()
+
+	if e.isRunning() {
+		e.startEntry(context.Background(), entry)
+	}
+	return TaskHandle{name: name, e: e}, nil
+}
+
+// RemoveTask stops and unregisters the named task, blocking until it has
+// exited. It is a no-op if the task is not registered.
+func (e *Engine) RemoveTask(name string) {
+	e.m.Lock()
+	entry, ok := e.tasks[name]
+	if ok {
+		delete(e.tasks, name)
+	}
+	e.m.Unlock()
+	if !ok {
+		return
+	}
+	if entry.cancel != nil {
+		entry.cancel()
+	}
+	if entry.done != nil {
+		<-entry.done
+	}
+}
+
+// ListTasks returns the names of all currently registered tasks, sorted.
+func (e *Engine) ListTasks() []string {
+	e.m.Lock()
+	defer e.m.Unlock()
+	names := make([]string, 0, len(e.tasks))
+	for name := range e.tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run claims the engine and drives all registered tasks concurrently, each
+// with its own cancel func and reconciliation loop, until ctx is canceled or
+// Shutdown is called. It returns ErrAlreadyStarted if the engine is already
+// started, including by a concurrent Start call still electing a leader.
+func (e *Engine) Run(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&e.state, int32(stateStopped), int32(stateStarting)) {
+		return ErrAlreadyStarted
+	}
+	err := e.runEntries(ctx)
+	atomic.StoreInt32(&e.state, int32(stateStopped))
+	return err
+}
+
+// runEntries performs one run cycle over all registered tasks, moving state
+// to stateRunning for its duration and back to stateStarting once it ends.
+// It does not itself claim stateStarting or release back to stateStopped:
+// callers own that bracket, so startWithLeader can keep the engine claimed
+// across repeated cycles while it re-elects a leader in between.
+func (e *Engine) runEntries(ctx context.Context) error {
+	e.m.Lock()
+	e.stopCh = make(chan struct{})
+	e.stoppedCh = make(chan struct{})
+	entries := make([]*taskEntry, 0, len(e.tasks))
+	for _, entry := range e.tasks {
+		entries = append(entries, entry)
+	}
+	e.m.Unlock()
+
+	atomic.StoreInt32(&e.state, int32(stateRunning))
+	for _, entry := range entries {
+		e.startEntry(ctx, entry)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-e.stopCh:
+	}
+	atomic.StoreInt32(&e.state, int32(stateStopping))
+
+	e.m.Lock()
+	entries = entries[:0]
+	for _, entry := range e.tasks {
+		entries = append(entries, entry)
+	}
+	stoppedCh := e.stoppedCh
+	e.m.Unlock()
+
+	for _, entry := range entries {
+		if entry.cancel != nil {
+			entry.cancel()
+		}
+	}
+	for _, entry := range entries {
+		if entry.done != nil {
+			<-entry.done
+		}
+	}
+
+	atomic.StoreInt32(&e.state, int32(stateStarting))
+	close(stoppedCh)
+	return nil
+}
+
+func (e *Engine) startEntry(ctx context.Context, entry *taskEntry) {
+	entryCtx, cancel := context.WithCancel(ctx)
+	entry.cancel = cancel
+	entry.done = make(chan struct{})
+	go e.runEntry(entryCtx, entry)
+}
+
+// runEntry dispatches to the reconciliation loop matching entry's
+// configuration: cron-style schedule, daily periods, or continuous.
+func (e *Engine) runEntry(ctx context.Context, entry *taskEntry) {
+	defer close(entry.done)
+
+	if sched := entry.opts.Schedule; sched != nil {
+		e.runEntryScheduled(ctx, entry, sched)
+		return
+	}
+	if len(entry.opts.Periods) == 0 {
+		if err := e.runTask(ctx, entry); err != nil && ctx.Err() == nil {
+			e.log.Errorf("[%s] Execution ends with error: %v", entry.name, err)
+		}
+		return
+	}
+	e.runEntryPeriods(ctx, entry)
+}
+
+// runEntryScheduled sleeps until each of sched's fire times in turn and
+// dispatches entry.task once per fire, bounded by entry.sem so at most
+// opts.effectiveConcurrency runs are in flight at once; a fire that would
+// exceed the cap is skipped rather than queued. It deliberately does not
+// delegate to runTask: that loop keeps re-running until
+// FailInterval/SuccessInterval says to stop, which for the zero-value
+// defaults never happens, so calling it here would spin entry.task in a
+// tight loop on every single fire instead of waiting for the schedule's
+// next instant. Backoff/FailInterval and MaxConsecutiveFailures are
+// honored across fires instead: a failure paces out the fires that follow
+// it by entry.nextRetry instead of the schedule's own cadence, and
+// MaxConsecutiveFailures logs once the run of failures crosses the
+// threshold, see TaskOptions.
+func (e *Engine) runEntryScheduled(ctx context.Context, entry *taskEntry, sched Scheduler) {
+	defer entry.wg.Wait()
+
+	for {
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			e.log.Errorf("[%s] schedule has no future fire time, stopping", entry.name)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if na := atomic.LoadInt64(&entry.nextRetry); na != 0 && time.Now().UnixNano() < na {
+			e.log.Warnf("[%s] backing off after consecutive failures, skipping this fire", entry.name)
+			continue
+		}
+
+		select {
+		case entry.sem <- struct{}{}:
+		default:
+			e.log.Warnf("[%s] max concurrency reached, skipping this fire", entry.name)
+			continue
+		}
+		entry.wg.Add(1)
+		go func() {
+			defer entry.wg.Done()
+			defer func() { <-entry.sem }()
+			e.runTaskOnceScheduled(ctx, entry)
+		}()
+	}
+}
+
+// runTaskOnceScheduled runs entry.task once and updates entry.failures and
+// entry.nextRetry for runEntryScheduled's next iteration: a failure paces
+// the following fires out using Backoff/FailInterval and logs once
+// MaxConsecutiveFailures is crossed; a success clears both.
+func (e *Engine) runTaskOnceScheduled(ctx context.Context, entry *taskEntry) {
+	err := e.runTaskOnce(ctx, entry)
+	if err == nil {
+		atomic.StoreInt32(&entry.failures, 0)
+		atomic.StoreInt64(&entry.nextRetry, 0)
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	e.log.Errorf("[%s] Execution ends with error: %v", entry.name, err)
+
+	opts := entry.opts
+	failures := atomic.AddInt32(&entry.failures, 1)
+	if opts.MaxConsecutiveFailures > 0 && int(failures) == opts.MaxConsecutiveFailures {
+		e.log.Errorf("[%s] circuit breaker tripped after %d consecutive failures", entry.name, failures)
+	}
+	sleep := e.failSleep(opts, int(failures))
+	metricsOrNop(opts.Metrics).Backoff(entry.name, sleep)
+	atomic.StoreInt64(&entry.nextRetry, time.Now().Add(sleep).UnixNano())
+}
+
+// runEntryPeriods runs entry.task for the duration of whichever of
+// entry.opts.Periods is currently open, sleeping exactly until the next
+// open/close transition instead of polling on a fixed interval.
+func (e *Engine) runEntryPeriods(ctx context.Context, entry *taskEntry) {
+	var windowCancel context.CancelFunc
+	wasOpen := false
+
+	for {
+		open, next := periodsState(entry.opts.Periods, time.Now())
+		switch {
+		case open && !wasOpen:
+			wasOpen = true
+			metricsOrNop(entry.opts.Metrics).WindowOpen(entry.name)
+			var windowCtx context.Context
+			windowCtx, windowCancel = context.WithCancel(ctx)
+			go func() {
+				if err := e.runTask(windowCtx, entry); err != nil && windowCtx.Err() == nil {
+					e.log.Errorf("[%s] Execution ends with error: %v", entry.name, err)
+				}
+				e.log.Debugf("[%s] The execution of the current time period is over, please wait for the next time period", entry.name)
+			}()
+		case !open && wasOpen:
+			wasOpen = false
+			windowCancel()
+			windowCancel = nil
+			metricsOrNop(entry.opts.Metrics).WindowClose(entry.name)
+		}
+
+		if next.IsZero() {
+			// periodsState only returns a zero next alongside open == false
+			// (Window.State never returns a zero close time for an open
+			// window), so windowCancel is always nil here in practice; cancel
+			// it anyway so this path can't leak a context if that ever stops
+			// holding.
+			if windowCancel != nil {
+				windowCancel()
+				metricsOrNop(entry.opts.Metrics).WindowClose(entry.name)
+			}
+			e.log.Errorf("[%s] no periods configured, stopping", entry.name)
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if windowCancel != nil {
+				windowCancel()
+				metricsOrNop(entry.opts.Metrics).WindowClose(entry.name)
+			}
+			e.log.Debugf("[%s] task stopped", entry.name)
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// runTaskOnce runs entry.task exactly once, wrapping the call with tracing
+// and metrics and tracking entry.inFlight for the duration of the run.
+func (e *Engine) runTaskOnce(ctx context.Context, entry *taskEntry) error {
+	atomic.AddInt32(&entry.inFlight, 1)
+	defer atomic.AddInt32(&entry.inFlight, -1)
+
+	opts := entry.opts
+	metrics := metricsOrNop(opts.Metrics)
+
+	runCtx := ctx
+	var endSpan func(error)
+	if opts.Tracer != nil {
+		runCtx, endSpan = opts.Tracer.Start(ctx, entry.name)
+	}
+	done := metrics.RunStart(entry.name)
+	start := time.Now()
+	err := entry.task.Run(runCtx)
+	done(err, time.Since(start))
+	if endSpan != nil {
+		endSpan(err)
+	}
+	return err
+}
+
+// runTask repeatedly runs entry.task via runTaskOnce, honoring FailInterval
+// and SuccessInterval between repeated runs. It is used for continuous tasks
+// (no Periods or Schedule) and for the duration of an open period window;
+// schedule-driven tasks instead call runTaskOnce directly once per fire, see
+// runEntryScheduled.
+func (e *Engine) runTask(ctx context.Context, entry *taskEntry) error {
+	opts := entry.opts
+	metrics := metricsOrNop(opts.Metrics)
+	var failures int
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		err := e.runTaskOnce(ctx, entry)
+		if err != nil {
+			failures++
+			if opts.MaxConsecutiveFailures > 0 && failures >= opts.MaxConsecutiveFailures {
+				e.log.Errorf("[%s] circuit breaker tripped after %d consecutive failures, stopping until the next window: %v", entry.name, failures, err)
+				return err
+			}
+			if opts.FailInterval < 0 {
+				return err
+			}
+			e.log.Errorf("[%s] Execution ends with error: %v", entry.name, err)
+			sleep := e.failSleep(opts, failures)
+			metrics.Backoff(entry.name, sleep)
+			e.log.Warnf("[%s] Will continue after %s", entry.name, sleep)
+			time.Sleep(sleep)
+			continue
+		}
+		failures = 0
+
+		if opts.SuccessInterval < 0 {
+			return nil
+		}
+		e.log.Debugf("[%s] Executed successfully, will continue after %dms", entry.name, opts.SuccessInterval)
+		time.Sleep(time.Duration(opts.SuccessInterval) * time.Millisecond)
+	}
+}
+
+// failSleep computes how long to wait after the Nth consecutive failure,
+// using opts.Backoff when set and falling back to the flat FailInterval
+// otherwise.
+func (e *Engine) failSleep(opts TaskOptions, failures int) time.Duration {
+	if opts.Backoff != nil {
+		return opts.Backoff.Next(failures)
+	}
+	return time.Duration(opts.FailInterval) * time.Millisecond
+}
@@ -0,0 +1,160 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingTask struct {
+	calls int32
+}
+
+func (t *countingTask) Run(ctx context.Context) error {
+	atomic.AddInt32(&t.calls, 1)
+	return nil
+}
+
+// TestScheduledTaskRunsOncePerFire guards against runEntryScheduled
+// delegating to the interval-looping runTask, which would spin the task in
+// a tight 0-delay loop on every fire instead of waiting for the schedule's
+// next instant.
+func TestScheduledTaskRunsOncePerFire(t *testing.T) {
+	sched, err := NewCronSchedule("@every 20ms", "")
+	if err != nil {
+		t.Fatalf("NewCronSchedule: %v", err)
+	}
+
+	e, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	task := &countingTask{}
+	if _, err := e.AddTask("t", task, TaskOptions{Schedule: sched}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	if err := e.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// 150ms at one fire every 20ms is at most ~8 fires; a spinning task
+	// would instead run millions of times.
+	if calls := atomic.LoadInt32(&task.calls); calls == 0 || calls > 20 {
+		t.Errorf("calls = %d, want roughly 1 call per ~20ms fire, got out of bounds", calls)
+	}
+}
+
+// slowTask sleeps longer than the schedule's fire interval and tracks the
+// highest number of concurrent Run calls observed.
+type slowTask struct {
+	sleep   time.Duration
+	running int32
+	maxSeen int32
+}
+
+func (t *slowTask) Run(ctx context.Context) error {
+	n := atomic.AddInt32(&t.running, 1)
+	for {
+		if seen := atomic.LoadInt32(&t.maxSeen); n > seen {
+			if atomic.CompareAndSwapInt32(&t.maxSeen, seen, n) {
+				break
+			}
+			continue
+		}
+		break
+	}
+	time.Sleep(t.sleep)
+	atomic.AddInt32(&t.running, -1)
+	return nil
+}
+
+// TestSingletonCapsConcurrency guards against Singleton/MaxConcurrency being
+// defaulted and parsed but never enforced: a task slower than its schedule's
+// fire interval must never have more than one run in flight at once.
+func TestSingletonCapsConcurrency(t *testing.T) {
+	sched, err := NewCronSchedule("@every 20ms", "")
+	if err != nil {
+		t.Fatalf("NewCronSchedule: %v", err)
+	}
+
+	e, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	task := &slowTask{sleep: 60 * time.Millisecond}
+	opts := TaskOptions{Schedule: sched, Singleton: true}
+	if _, err := e.AddTask("t", task, opts); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := e.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if max := atomic.LoadInt32(&task.maxSeen); max > 1 {
+		t.Errorf("max concurrent runs = %d, want at most 1 with Singleton set", max)
+	}
+}
+
+type failingTask struct {
+	calls int32
+}
+
+func (t *failingTask) Run(ctx context.Context) error {
+	atomic.AddInt32(&t.calls, 1)
+	return errors.New("boom")
+}
+
+// TestScheduledFailureAppliesFailInterval guards against FailInterval being
+// parsed but never consulted under Schedule mode: a task that always fails
+// must be paced out by FailInterval between fires instead of running on
+// every one of the schedule's own, much faster, fire times.
+func TestScheduledFailureAppliesFailInterval(t *testing.T) {
+	sched, err := NewCronSchedule("@every 15ms", "")
+	if err != nil {
+		t.Fatalf("NewCronSchedule: %v", err)
+	}
+
+	e, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	task := &failingTask{}
+	opts := TaskOptions{Schedule: sched, FailInterval: 100}
+	if _, err := e.AddTask("t", task, opts); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := e.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// 200ms at one fire every 15ms is ~13 fires; with a 100ms FailInterval
+	// applied after each failure, at most 2-3 of them should actually run.
+	if calls := atomic.LoadInt32(&task.calls); calls == 0 || calls > 4 {
+		t.Errorf("calls = %d, want roughly 2-3 paced by FailInterval, got out of bounds", calls)
+	}
+}
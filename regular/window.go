@@ -0,0 +1,162 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import "time"
+
+// DaySet is a bitmask of time.Weekday values a Window's start time may fall
+// on. A zero DaySet means every day.
+type DaySet uint8
+
+// NewDaySet builds a DaySet from one or more weekdays.
+func NewDaySet(days ...time.Weekday) DaySet {
+	var s DaySet
+	for _, d := range days {
+		s |= 1 << uint(d)
+	}
+	return s
+}
+
+// Has reports whether d is included in the set. An empty set includes
+// every day.
+func (s DaySet) Has(d time.Weekday) bool {
+	if s == 0 {
+		return true
+	}
+	return s&(1<<uint(d)) != 0
+}
+
+// Window describes a recurring time-of-day window, such as "Mon-Fri
+// 22:00-02:00" or "every day 09:30:15-09:30:45", evaluated in Location.
+type Window struct {
+	// Start and End are offsets since local midnight. End <= Start means
+	// the window crosses midnight: it opens at Start and closes at End the
+	// following day.
+	Start, End time.Duration
+	// Days restricts which days a window instance may start on. A zero
+	// value (empty DaySet) means every day.
+	Days DaySet
+	// Location is the time zone Start/End/Days are evaluated in. Defaults
+	// to time.Local when nil.
+	Location *time.Location
+}
+
+// duration returns how long a single window instance stays open, treating
+// End <= Start as crossing midnight.
+func (w Window) duration() time.Duration {
+	d := w.End - w.Start
+	if d <= 0 {
+		d += 24 * time.Hour
+	}
+	return d
+}
+
+// instance returns the [start, end) of the window instance that would
+// begin on the local calendar day starting at dayMidnight, and whether
+// Days allows it to start that day.
+func (w Window) instance(dayMidnight time.Time) (start, end time.Time, allowed bool) {
+	start = dayMidnight.Add(w.Start)
+	end = start.Add(w.duration())
+	allowed = w.Days.Has(dayMidnight.Weekday())
+	return
+}
+
+// State reports whether the window is open at now, and the next instant at
+// which that answer would change: the close time if open, otherwise the
+// next allowed start time. It returns the zero Time if Days excludes every
+// day (which Has never does, since an empty DaySet means every day).
+func (w Window) State(now time.Time) (open bool, nextTransition time.Time) {
+	loc := w.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	local := now.In(loc)
+	todayMidnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	// A window spans at most 24h, so only an instance starting yesterday
+	// or today can possibly contain "local" right now.
+	if start, end, allowed := w.instance(todayMidnight.AddDate(0, 0, -1)); allowed && !local.Before(start) && local.Before(end) {
+		return true, end
+	}
+	if start, end, allowed := w.instance(todayMidnight); allowed && !local.Before(start) && local.Before(end) {
+		return true, end
+	}
+
+	// Not open: scan forward for the next allowed start time. Days repeats
+	// weekly, so 7 days always finds one when any day is allowed.
+	for i := 0; i < 8; i++ {
+		start, _, allowed := w.instance(todayMidnight.AddDate(0, 0, i))
+		if allowed && !start.Before(local) {
+			return false, start
+		}
+	}
+	return false, time.Time{}
+}
+
+// periodsState reports whether any of periods is open at now, and the
+// earliest instant at which any of their open/closed states could change.
+// Engine.runEntryPeriods sleeps until that instant instead of polling.
+func periodsState(periods []*Period, now time.Time) (open bool, next time.Time) {
+	for _, p := range periods {
+		o, n := p.window().State(now)
+		if o {
+			open = true
+		}
+		if n.IsZero() {
+			continue
+		}
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return open, next
+}
+
+// CheckTime reports, for the daily window [startHour:startMinute,
+// endHour:endMinute) evaluated in the local time zone, whether
+// currentHour:currentMinute has reached the window's start and whether it
+// has reached its end; the window contains it exactly when start is true and
+// end is false.
+//
+// Deprecated: use Window.State, which also supports per-day masks, seconds
+// precision, and returns the exact next transition instead of requiring a
+// 1-minute poll.
+func CheckTime(startHour, startMinute, endHour, endMinute, currentHour, currentMinute int) (start bool, end bool) {
+	startTime := time.Date(0, 0, 0, startHour, startMinute, 0, 0, time.Local)
+	endTime := time.Date(0, 0, 0, endHour, endMinute, 0, 0, time.Local)
+	currentTime := time.Date(0, 0, 0, currentHour, currentMinute, 0, 0, time.Local)
+
+	since := startTime.Sub(endTime)
+	if since == 0 {
+		start = true
+		return
+	} else if since < 0 {
+		if currentTime.Sub(startTime) >= 0 {
+			start = true
+		}
+		if currentTime.Sub(endTime) >= 0 {
+			end = true
+		}
+	} else {
+		if currentTime.Sub(endTime) >= 0 {
+			end = true
+		}
+		if currentTime.Sub(startTime) >= 0 || !end {
+			start = true
+			end = false
+		}
+	}
+	return
+}
@@ -0,0 +1,217 @@
+// Copyright © 2022 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regular
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowState(t *testing.T) {
+	utc := time.UTC
+	mustTime := func(layout, value string) time.Time {
+		tm, err := time.ParseInLocation(layout, value, utc)
+		if err != nil {
+			t.Fatalf("parse %q: %v", value, err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		name       string
+		window     Window
+		now        time.Time
+		wantOpen   bool
+		wantNext   time.Time
+	}{
+		{
+			name: "before window opens today",
+			window: Window{
+				Start: 9 * time.Hour,
+				End:   18 * time.Hour,
+			},
+			now:      mustTime("2006-01-02 15:04:05", "2024-06-10 08:00:00"),
+			wantOpen: false,
+			wantNext: mustTime("2006-01-02 15:04:05", "2024-06-10 09:00:00"),
+		},
+		{
+			name: "inside a same-day window",
+			window: Window{
+				Start: 9 * time.Hour,
+				End:   18 * time.Hour,
+			},
+			now:      mustTime("2006-01-02 15:04:05", "2024-06-10 12:00:00"),
+			wantOpen: true,
+			wantNext: mustTime("2006-01-02 15:04:05", "2024-06-10 18:00:00"),
+		},
+		{
+			name: "after a same-day window closes",
+			window: Window{
+				Start: 9 * time.Hour,
+				End:   18 * time.Hour,
+			},
+			now:      mustTime("2006-01-02 15:04:05", "2024-06-10 19:00:00"),
+			wantOpen: false,
+			wantNext: mustTime("2006-01-02 15:04:05", "2024-06-11 09:00:00"),
+		},
+		{
+			name: "cross-midnight window open before midnight",
+			window: Window{
+				Start: 22 * time.Hour,
+				End:   2 * time.Hour,
+			},
+			now:      mustTime("2006-01-02 15:04:05", "2024-06-10 23:30:00"),
+			wantOpen: true,
+			wantNext: mustTime("2006-01-02 15:04:05", "2024-06-11 02:00:00"),
+		},
+		{
+			name: "cross-midnight window open after midnight",
+			window: Window{
+				Start: 22 * time.Hour,
+				End:   2 * time.Hour,
+			},
+			now:      mustTime("2006-01-02 15:04:05", "2024-06-11 01:00:00"),
+			wantOpen: true,
+			wantNext: mustTime("2006-01-02 15:04:05", "2024-06-11 02:00:00"),
+		},
+		{
+			name: "cross-midnight window closed between end and start",
+			window: Window{
+				Start: 22 * time.Hour,
+				End:   2 * time.Hour,
+			},
+			now:      mustTime("2006-01-02 15:04:05", "2024-06-11 12:00:00"),
+			wantOpen: false,
+			wantNext: mustTime("2006-01-02 15:04:05", "2024-06-11 22:00:00"),
+		},
+		{
+			name: "seconds precision window",
+			window: Window{
+				Start: 9*time.Hour + 30*time.Minute + 15*time.Second,
+				End:   9*time.Hour + 30*time.Minute + 45*time.Second,
+			},
+			now:      mustTime("2006-01-02 15:04:05", "2024-06-10 09:30:30"),
+			wantOpen: true,
+			wantNext: mustTime("2006-01-02 15:04:05", "2024-06-10 09:30:45"),
+		},
+		{
+			name: "empty Days means every day",
+			window: Window{
+				Start: 9 * time.Hour,
+				End:   18 * time.Hour,
+			},
+			// 2024-06-10 is a Monday; Days is zero, so it should still be
+			// allowed to open.
+			now:      mustTime("2006-01-02 15:04:05", "2024-06-10 09:00:00"),
+			wantOpen: true,
+			wantNext: mustTime("2006-01-02 15:04:05", "2024-06-10 18:00:00"),
+		},
+		{
+			name: "per-day mask: Mon-Fri 22:00-02:00 stays open from Friday night into Saturday",
+			window: Window{
+				Start: 22 * time.Hour,
+				End:   2 * time.Hour,
+				Days:  NewDaySet(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday),
+			},
+			// 2024-06-14 is a Friday.
+			now:      mustTime("2006-01-02 15:04:05", "2024-06-14 23:00:00"),
+			wantOpen: true,
+			wantNext: mustTime("2006-01-02 15:04:05", "2024-06-15 02:00:00"),
+		},
+		{
+			name: "per-day mask: Saturday night is excluded even though it follows an open Friday",
+			window: Window{
+				Start: 22 * time.Hour,
+				End:   2 * time.Hour,
+				Days:  NewDaySet(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday),
+			},
+			// 2024-06-15 is a Saturday.
+			now:       mustTime("2006-01-02 15:04:05", "2024-06-15 23:00:00"),
+			wantOpen:  false,
+			wantNext:  mustTime("2006-01-02 15:04:05", "2024-06-17 22:00:00"), // next Monday
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open, next := tt.window.State(tt.now)
+			if open != tt.wantOpen {
+				t.Errorf("open = %v, want %v", open, tt.wantOpen)
+			}
+			if !next.Equal(tt.wantNext) {
+				t.Errorf("nextTransition = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+// TestWindowStateDST covers the US spring-forward and fall-back
+// transitions in America/New_York, where a naive hour/minute-of-day
+// comparison would misbehave. time.Time arithmetic handles both correctly
+// because Window.State operates on absolute instants, not local clock
+// fields directly.
+func TestWindowStateDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+
+	w := Window{
+		Start:    1 * time.Hour,
+		End:      4 * time.Hour,
+		Location: loc,
+	}
+
+	// 2024-03-10: clocks spring forward from 02:00 to 03:00 EST->EDT, so
+	// the 02:00-03:00 wall-clock hour never happens. State computes
+	// Start/End as elapsed time since midnight, so the window's close time
+	// lands an hour later on the wall clock than a naive hour arithmetic
+	// would suggest (05:00, not 04:00) - it stays open for a fixed amount
+	// of real time across the gap instead of producing an invalid instant.
+	springForward := time.Date(2024, 3, 10, 1, 30, 0, 0, loc)
+	open, next := w.State(springForward)
+	if !open {
+		t.Errorf("expected window open during spring-forward day at 01:30, got closed")
+	}
+	wantNext := time.Date(2024, 3, 10, 5, 0, 0, 0, loc)
+	if !next.Equal(wantNext) {
+		t.Errorf("nextTransition = %v, want %v", next, wantNext)
+	}
+
+	// 2024-11-03: clocks fall back from 02:00 to 01:00 EDT->EST, so 01:30
+	// local time occurs twice. State must still resolve to a single,
+	// well-defined instant for the window's close.
+	fallBack := time.Date(2024, 11, 3, 1, 30, 0, 0, loc)
+	open, next = w.State(fallBack)
+	if !open {
+		t.Errorf("expected window open during fall-back day at 01:30, got closed")
+	}
+	if next.Before(fallBack) {
+		t.Errorf("nextTransition %v must not be before now %v", next, fallBack)
+	}
+}
+
+// TestCheckTimeDeprecatedShim pins the legacy behavior the Window.State
+// replacement preserves for existing callers.
+func TestCheckTimeDeprecatedShim(t *testing.T) {
+	start, end := CheckTime(9, 0, 18, 0, 12, 0)
+	if !start || end {
+		t.Errorf("CheckTime(9,0,18,0,12,0) = (%v,%v), want (true,false)", start, end)
+	}
+	start, end = CheckTime(9, 0, 18, 0, 19, 0)
+	if !start || !end {
+		t.Errorf("CheckTime(9,0,18,0,19,0) = (%v,%v), want (true,true)", start, end)
+	}
+}